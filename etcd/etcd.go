@@ -4,13 +4,17 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -18,11 +22,23 @@ import (
 	etcd2 "github.com/coreos/etcd/client"
 	"github.com/gliderlabs/registrator/bridge"
 	etcd "gopkg.in/coreos/go-etcd.v0/etcd"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	etcd3 "go.etcd.io/etcd/client/v3"
 )
 
 var certFile = flag.String("etcd-cert-file", "", "identify HTTPS client using this SSL certificate file")
 var keyFile = flag.String("etcd-key-file", "", "identify HTTPS client using this SSL key file")
 var caFile = flag.String("etcd-ca-file", "", "verify certificates of HTTPS-enabled servers using this CA bundle")
+var username = flag.String("etcd-username", "", "username for etcd user/role authentication")
+var password = flag.String("etcd-password", "", "password for etcd user/role authentication")
+var apiVersion = flag.String("etcd-api", "", "etcd client API version to use (v0, v2 or v3); auto-detected from the server when empty")
+var watch = flag.Bool("etcd-watch", false, "watch for externally deleted/expired keys under the registrator path and re-register immediately instead of waiting for the next TTL refresh")
+var dialTimeout = flag.Duration("etcd-dial-timeout", 30*time.Second, "timeout for dialing an etcd endpoint")
+var dialKeepAlive = flag.Duration("etcd-dial-keepalive", 30*time.Second, "keep-alive period for connections to etcd endpoints")
+var requestTimeout = flag.Duration("etcd-request-timeout", 3*time.Second, "per-request timeout for the etcd v2/v3 clients")
+var maxIdleConnsPerHost = flag.Int("etcd-max-idle-conns-per-host", 0, "maximum idle HTTP connections to keep open per etcd endpoint (0 uses the net/http default)")
+var endpointRetries = flag.Int("etcd-endpoint-retries", 3, "number of times to retry a Register/Deregister/Ping against the next endpoint before giving up")
 
 func init() {
 	bridge.Register(new(Factory), "etcd")
@@ -43,6 +59,15 @@ func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
 		scheme = "https://"
 	}
 
+	user := *username
+	pass := *password
+	if uri.User != nil {
+		user = uri.User.Username()
+		if p, set := uri.User.Password(); set {
+			pass = p
+		}
+	}
+
 	if uri.Host != "" {
 		urls = append(urls, scheme+uri.Host)
 	} else {
@@ -60,15 +85,50 @@ func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
 	defer res.Body.Close()
 	body, _ := ioutil.ReadAll(res.Body)
 
+	isV3 := *apiVersion == "v3"
+	if *apiVersion == "" {
+		if match, _ := regexp.Match("\"etcdserver\":\"3\\.", body); match == true {
+			isV3 = true
+		}
+	}
+
+	if isV3 {
+		log.Println("etcd: using v3 client")
+		cfg3 := etcd3.Config{
+			Endpoints:   urls,
+			DialTimeout: *dialTimeout,
+			TLS:         transport.TLSClientConfig,
+			Username:    user,
+			Password:    pass,
+		}
+
+		client3, err := etcd3.New(cfg3)
+		if err != nil {
+			log.Fatal("etcd: no valid etcd client could be created", err)
+		}
+
+		adapter := &EtcdAdapter{client3: client3, path: uri.Path, leases: make(map[string]etcd3.LeaseID), services: make(map[string]*bridge.Service)}
+		if *watch {
+			go adapter.watchV3()
+		}
+		return adapter
+	}
+
 	if match, _ := regexp.Match("0\\.4\\.*", body); match == true {
 		log.Println("etcd: using v0 client")
-		return &EtcdAdapter{client: etcd.NewClient(urls), path: uri.Path}
+		client := etcd.NewClient(urls)
+		if user != "" {
+			client.SetCredentials(user, pass)
+		}
+		return &EtcdAdapter{client: client, path: uri.Path, services: make(map[string]*bridge.Service)}
 	}
 
 	cfg := etcd2.Config{
 		Endpoints:               urls,
-		HeaderTimeoutPerRequest: time.Duration(3) * time.Second,
+		HeaderTimeoutPerRequest: *requestTimeout,
 		Transport:               transport,
+		Username:                user,
+		Password:                pass,
 	}
 
 	client2, err := etcd2.New(cfg)
@@ -76,26 +136,63 @@ func (f *Factory) New(uri *url.URL) bridge.RegistryAdapter {
 		log.Fatal("etcd: no valid etcd client could be created", err)
 	}
 
-	return &EtcdAdapter{client2: client2, path: uri.Path}
+	adapter := &EtcdAdapter{client2: client2, kapi2: etcd2.NewKeysAPI(client2), path: uri.Path, services: make(map[string]*bridge.Service)}
+	if *watch {
+		go adapter.watchV2()
+	}
+	return adapter
+}
+
+// keysAPI is the subset of etcd2.KeysAPI that EtcdAdapter uses, narrowed out
+// so a fake can be substituted in tests without standing up a real v2
+// server.
+type keysAPI interface {
+	Get(ctx context.Context, key string, opts *etcd2.GetOptions) (*etcd2.Response, error)
+	Set(ctx context.Context, key, value string, opts *etcd2.SetOptions) (*etcd2.Response, error)
+	Delete(ctx context.Context, key string, opts *etcd2.DeleteOptions) (*etcd2.Response, error)
+	Watcher(key string, opts *etcd2.WatcherOptions) etcd2.Watcher
 }
 
 type EtcdAdapter struct {
 	client  *etcd.Client
 	client2 etcd2.Client
+	kapi2   keysAPI
+	client3 *etcd3.Client
 
 	path string
+
+	leasesMu sync.Mutex
+	leases   map[string]etcd3.LeaseID
+
+	servicesMu sync.Mutex
+	services   map[string]*bridge.Service
+
+	watchIndexMu sync.Mutex
+	watchIndex   uint64
 }
 
 func (r *EtcdAdapter) Ping() error {
-	r.syncEtcdCluster()
+	return r.withRetry("ping", r.doPing)
+}
+
+func (r *EtcdAdapter) doPing() error {
+	if *watch {
+		r.watchIndexMu.Lock()
+		idx := r.watchIndex
+		r.watchIndexMu.Unlock()
+		log.Println("etcd: current watch index:", idx)
+	}
 
 	var err error
-	if r.client != nil {
+	if r.client3 != nil {
+		ctx, cancel := requestContext()
+		defer cancel()
+		_, err = r.client3.Get(ctx, "/")
+	} else if r.client != nil {
 		rr := etcd.NewRawRequest("GET", "version", nil, nil)
 		_, err = r.client.SendRequest(rr)
 	} else {
-		kapi := etcd2.NewKeysAPI(r.client2)
-		_, err = kapi.Get(context.Background(), "/", &etcd2.GetOptions{})
+		_, err = r.kapi2.Get(context.Background(), "/", &etcd2.GetOptions{})
 	}
 
 	if err != nil {
@@ -104,7 +201,51 @@ func (r *EtcdAdapter) Ping() error {
 	return nil
 }
 
+// withRetry runs op, and on failure calls syncEtcdCluster to rotate through
+// the known endpoints and retries with exponential backoff, up to
+// -etcd-endpoint-retries times. This keeps Register/Deregister/Ping from
+// failing permanently on a single transient endpoint hiccup.
+func (r *EtcdAdapter) withRetry(name string, op func() error) error {
+	backoff := 100 * time.Millisecond
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		r.syncEtcdCluster()
+
+		err = op()
+		if err == nil {
+			return nil
+		}
+		if attempt >= *endpointRetries {
+			break
+		}
+
+		log.Printf("etcd: %s failed, retrying against next endpoint: %v", name, err)
+		time.Sleep(backoff)
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+	}
+	return err
+}
+
+// requestContext bounds a single v3 RPC by -etcd-request-timeout, mirroring
+// the v2 client's HeaderTimeoutPerRequest. Not used for the long-lived
+// Watch stream.
+func requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), *requestTimeout)
+}
+
 func (r *EtcdAdapter) syncEtcdCluster() {
+	if r.client3 != nil {
+		// the v3 client balances requests across its configured
+		// endpoints itself; its member list is not re-synced here.
+		return
+	}
+	if r.client == nil && r.client2 == nil {
+		return
+	}
+
 	var result bool
 	if r.client != nil {
 		result = r.client.SyncCluster()
@@ -121,37 +262,104 @@ func (r *EtcdAdapter) syncEtcdCluster() {
 }
 
 func (r *EtcdAdapter) Register(service *bridge.Service) error {
-	r.syncEtcdCluster()
+	return r.withRetry("register", func() error { return r.doRegister(service) })
+}
 
+func (r *EtcdAdapter) doRegister(service *bridge.Service) error {
 	path := r.path + "/" + service.Name + "/" + service.ID
 	port := strconv.Itoa(service.Port)
 	addr := net.JoinHostPort(service.IP, port)
 
 	var err error
-	if r.client != nil {
+	if r.client3 != nil {
+		ctx, cancel := requestContext()
+		defer cancel()
+
+		// v3 leases can't express "no TTL" the way v0/v2 Set(TTL: 0)
+		// does (the server floors/rejects non-positive durations), so
+		// a TTL<=0 service is registered with a plain Put and no
+		// lease to keep the "registered until explicit Deregister"
+		// contract consistent across client versions.
+		if service.TTL <= 0 {
+			_, err := r.client3.Put(ctx, path, addr)
+			if err != nil {
+				log.Println("etcd: failed to register service:", err)
+				return err
+			}
+			r.leasesMu.Lock()
+			delete(r.leases, path)
+			r.leasesMu.Unlock()
+			r.rememberService(path, service)
+			return nil
+		}
+
+		lease, err := r.client3.Grant(ctx, int64(service.TTL))
+		if err != nil {
+			log.Println("etcd: failed to register service:", err)
+			return err
+		}
+		_, err = r.client3.Put(ctx, path, addr, etcd3.WithLease(lease.ID))
+		if err != nil {
+			log.Println("etcd: failed to register service:", err)
+			return err
+		}
+		r.leasesMu.Lock()
+		r.leases[path] = lease.ID
+		r.leasesMu.Unlock()
+		r.rememberService(path, service)
+		return nil
+	} else if r.client != nil {
 		_, err = r.client.Set(path, addr, uint64(service.TTL))
 	} else {
-		kapi := etcd2.NewKeysAPI(r.client2)
-		_, err = kapi.Set(context.Background(), path, addr, &etcd2.SetOptions{TTL: time.Duration(service.TTL) * time.Second})
+		_, err = r.kapi2.Set(context.Background(), path, addr, &etcd2.SetOptions{TTL: time.Duration(service.TTL) * time.Second})
 	}
 
 	if err != nil {
 		log.Println("etcd: failed to register service:", err)
+		return err
 	}
-	return err
+	r.rememberService(path, service)
+	return nil
+}
+
+func (r *EtcdAdapter) rememberService(path string, service *bridge.Service) {
+	r.servicesMu.Lock()
+	r.services[path] = service
+	r.servicesMu.Unlock()
+}
+
+func (r *EtcdAdapter) forgetService(path string) {
+	r.servicesMu.Lock()
+	delete(r.services, path)
+	r.servicesMu.Unlock()
 }
 
 func (r *EtcdAdapter) Deregister(service *bridge.Service) error {
-	r.syncEtcdCluster()
+	return r.withRetry("deregister", func() error { return r.doDeregister(service) })
+}
 
+func (r *EtcdAdapter) doDeregister(service *bridge.Service) error {
 	path := r.path + "/" + service.Name + "/" + service.ID
 
+	// Forget the service before issuing the delete, not after: watchV2/
+	// watchV3 run on their own goroutine and can observe the resulting
+	// delete event before this call returns. If the cache entry were
+	// still present at that point, reregister() would re-create the key
+	// we just deliberately deregistered.
+	r.forgetService(path)
+
 	var err error
-	if r.client != nil {
+	if r.client3 != nil {
+		ctx, cancel := requestContext()
+		_, err = r.client3.Delete(ctx, path)
+		cancel()
+		r.leasesMu.Lock()
+		delete(r.leases, path)
+		r.leasesMu.Unlock()
+	} else if r.client != nil {
 		_, err = r.client.Delete(path, false)
 	} else {
-		kapi := etcd2.NewKeysAPI(r.client2)
-		_, err = kapi.Delete(context.Background(), path, &etcd2.DeleteOptions{})
+		_, err = r.kapi2.Delete(context.Background(), path, &etcd2.DeleteOptions{})
 	}
 
 	if err != nil {
@@ -161,47 +369,401 @@ func (r *EtcdAdapter) Deregister(service *bridge.Service) error {
 }
 
 func (r *EtcdAdapter) Refresh(service *bridge.Service) error {
+	if r.client3 != nil {
+		path := r.path + "/" + service.Name + "/" + service.ID
+
+		r.leasesMu.Lock()
+		lease, ok := r.leases[path]
+		r.leasesMu.Unlock()
+		if !ok {
+			return r.Register(service)
+		}
+
+		ctx, cancel := requestContext()
+		_, err := r.client3.KeepAliveOnce(ctx, lease)
+		cancel()
+		if err != nil {
+			log.Println("etcd: failed to refresh lease, re-registering service:", err)
+			return r.Register(service)
+		}
+		return nil
+	}
 	return r.Register(service)
 }
 
+// watchV2 observes delete/expire events under r.path using the v2 Watcher
+// API and immediately re-registers the affected service from the
+// in-memory service cache rather than waiting for the next TTL refresh. It
+// reconnects with exponential backoff if the watch channel errors.
+func (r *EtcdAdapter) watchV2() {
+	backoff := time.Second
+
+	for {
+		r.watchIndexMu.Lock()
+		afterIndex := r.watchIndex
+		r.watchIndexMu.Unlock()
+
+		watcher := r.kapi2.Watcher(r.path, &etcd2.WatcherOptions{Recursive: true, AfterIndex: afterIndex})
+		for {
+			resp, err := watcher.Next(context.Background())
+			if err != nil {
+				log.Println("etcd: watch error, reconnecting:", err)
+				time.Sleep(backoff)
+				if backoff < 30*time.Second {
+					backoff *= 2
+				}
+				break
+			}
+			backoff = time.Second
+
+			r.watchIndexMu.Lock()
+			r.watchIndex = resp.Node.ModifiedIndex
+			r.watchIndexMu.Unlock()
+
+			if resp.Action != "delete" && resp.Action != "expire" {
+				continue
+			}
+			r.reregister(resp.Node.Key)
+		}
+	}
+}
+
+// watchV3 is the v3 equivalent of watchV2, using Watch with WithPrefix.
+func (r *EtcdAdapter) watchV3() {
+	backoff := time.Second
+
+	for {
+		r.watchIndexMu.Lock()
+		rev := r.watchIndex
+		r.watchIndexMu.Unlock()
+
+		opts := []etcd3.OpOption{etcd3.WithPrefix()}
+		if rev > 0 {
+			opts = append(opts, etcd3.WithRev(int64(rev)+1))
+		}
+
+		watchChan := r.client3.Watch(context.Background(), r.path+"/", opts...)
+		for resp := range watchChan {
+			if resp.Err() != nil {
+				log.Println("etcd: watch error, reconnecting:", resp.Err())
+				break
+			}
+			backoff = time.Second
+
+			r.watchIndexMu.Lock()
+			r.watchIndex = uint64(resp.Header.Revision)
+			r.watchIndexMu.Unlock()
+
+			for _, ev := range resp.Events {
+				if ev.Type != mvccpb.DELETE {
+					continue
+				}
+				r.reregister(string(ev.Kv.Key))
+			}
+		}
+
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// reregister looks up the service last registered at path in the
+// in-memory cache and re-registers it, used when a watch observes the key
+// disappearing outside of a normal Deregister call.
+func (r *EtcdAdapter) reregister(path string) {
+	r.servicesMu.Lock()
+	service, ok := r.services[path]
+	r.servicesMu.Unlock()
+	if !ok {
+		return
+	}
+
+	log.Println("etcd: re-registering service after external delete/expire:", path)
+	if err := r.Register(service); err != nil {
+		log.Println("etcd: failed to re-register service:", err)
+	}
+}
+
 func (r *EtcdAdapter) Services() ([]*bridge.Service, error) {
-	return []*bridge.Service{}, nil
+	r.syncEtcdCluster()
+
+	if r.client3 != nil {
+		ctx, cancel := requestContext()
+		resp, err := r.client3.Get(ctx, r.path+"/", etcd3.WithPrefix())
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		services := []*bridge.Service{}
+		for _, kv := range resp.Kvs {
+			rel := string(kv.Key[len(r.path)+1:])
+			name := path.Dir(rel)
+			id := path.Base(rel)
+			if name == "." {
+				continue
+			}
+			service, err := serviceFromNode(name, id, string(kv.Value))
+			if err != nil {
+				log.Println("etcd: failed to parse service:", err)
+				continue
+			}
+			services = append(services, service)
+		}
+		return services, nil
+	}
+
+	var node *etcd2.Node
+	var node0 *etcd.Node
+
+	if r.client != nil {
+		resp, err := r.client.Get(r.path, false, true)
+		if err != nil {
+			if isKeyNotFound(err) {
+				return []*bridge.Service{}, nil
+			}
+			return nil, err
+		}
+		node0 = resp.Node
+	} else {
+		resp, err := r.kapi2.Get(context.Background(), r.path, &etcd2.GetOptions{Recursive: true})
+		if err != nil {
+			if isKeyNotFound(err) {
+				return []*bridge.Service{}, nil
+			}
+			return nil, err
+		}
+		node = resp.Node
+	}
+
+	services := []*bridge.Service{}
+
+	if node0 != nil {
+		for _, nameNode := range node0.Nodes {
+			if !nameNode.Dir {
+				continue
+			}
+			name := path.Base(nameNode.Key)
+			for _, idNode := range nameNode.Nodes {
+				service, err := serviceFromNode(name, path.Base(idNode.Key), idNode.Value)
+				if err != nil {
+					log.Println("etcd: failed to parse service:", err)
+					continue
+				}
+				services = append(services, service)
+			}
+		}
+		return services, nil
+	}
+
+	for _, nameNode := range node.Nodes {
+		if !nameNode.Dir {
+			continue
+		}
+		name := path.Base(nameNode.Key)
+		for _, idNode := range nameNode.Nodes {
+			service, err := serviceFromNode(name, path.Base(idNode.Key), idNode.Value)
+			if err != nil {
+				log.Println("etcd: failed to parse service:", err)
+				continue
+			}
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+func serviceFromNode(name, id, value string) (*bridge.Service, error) {
+	host, portStr, err := net.SplitHostPort(value)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	return &bridge.Service{Name: name, ID: id, IP: host, Port: port}, nil
+}
+
+func isKeyNotFound(err error) bool {
+	if etcdErr, ok := err.(*etcd.EtcdError); ok {
+		return etcdErr.ErrorCode == etcd.EcodeKeyNotFound
+	}
+	if etcd2Err, ok := err.(etcd2.Error); ok {
+		return etcd2Err.Code == etcd2.ErrorCodeKeyNotFound
+	}
+	return false
+}
+
+// tlsReloader keeps the CA pool and client certificate used by the etcd
+// transport fresh by re-reading -etcd-ca-file whenever its mtime changes,
+// and the -etcd-cert-file/-etcd-key-file pair whenever the cert file's mtime
+// changes, so a PKI rotation doesn't require restarting registrator.
+type tlsReloader struct {
+	mu sync.RWMutex
+
+	pool     *x509.CertPool
+	poolTime time.Time
+
+	cert     *tls.Certificate
+	certTime time.Time
+}
+
+func newTLSReloader() (*tlsReloader, error) {
+	t := &tlsReloader{}
+	if err := t.reloadCA(); err != nil {
+		return nil, err
+	}
+	if err := t.reloadCert(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tlsReloader) reloadCA() error {
+	if *caFile == "" {
+		return nil
+	}
+
+	info, err := os.Stat(*caFile)
+	if err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	unchanged := t.pool != nil && info.ModTime().Equal(t.poolTime)
+	t.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	certBytes, err := ioutil.ReadFile(*caFile)
+	if err != nil {
+		return err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certBytes) {
+		return fmt.Errorf("etcd: no certificates found in %s", *caFile)
+	}
+
+	t.mu.Lock()
+	t.pool = pool
+	t.poolTime = info.ModTime()
+	t.mu.Unlock()
+
+	log.Println("etcd: reloaded CA bundle from", *caFile)
+	return nil
+}
+
+func (t *tlsReloader) reloadCert() error {
+	if *certFile == "" || *keyFile == "" {
+		return nil
+	}
+
+	info, err := os.Stat(*certFile)
+	if err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	unchanged := t.cert != nil && info.ModTime().Equal(t.certTime)
+	t.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.cert = &cert
+	t.certTime = info.ModTime()
+	t.mu.Unlock()
+
+	log.Println("etcd: reloaded client certificate from", *certFile)
+	return nil
+}
+
+// getClientCertificate is used as tls.Config.GetClientCertificate so the
+// cert file's mtime is checked on every handshake and the key pair re-read
+// from disk when it changed, instead of being baked in at startup.
+func (t *tlsReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if err := t.reloadCert(); err != nil {
+		log.Println("etcd: failed to reload client certificate, reusing previous one:", err)
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.cert == nil {
+		return &tls.Certificate{}, nil
+	}
+	return t.cert, nil
+}
+
+// verifyConnection is used as tls.Config.VerifyConnection (with
+// InsecureSkipVerify set so the stdlib doesn't verify against a stale pool
+// first) so CA rotation takes effect without a restart. Unlike
+// VerifyPeerCertificate, it still receives the ServerName/SNI the
+// transport dialed, so hostname verification is preserved alongside the
+// hot-reloaded CA pool.
+func (t *tlsReloader) verifyConnection(cs tls.ConnectionState) error {
+	if err := t.reloadCA(); err != nil {
+		log.Println("etcd: failed to reload CA bundle, reusing previous one:", err)
+	}
+
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("etcd: no peer certificate presented")
+	}
+
+	t.mu.RLock()
+	pool := t.pool
+	t.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
 }
 
 func createTransport() (*http.Transport, error) {
 	var transport = &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		Dial: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
+			Timeout:   *dialTimeout,
+			KeepAlive: *dialKeepAlive,
 		}).Dial,
 		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: *maxIdleConnsPerHost,
 	}
 
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: false,
 	}
 
-	if *caFile != "" {
-		certBytes, err := ioutil.ReadFile(*caFile)
+	if *caFile != "" || (*certFile != "" && *keyFile != "") {
+		reloader, err := newTLSReloader()
 		if err != nil {
 			return &http.Transport{}, err
 		}
 
-		caCertPool := x509.NewCertPool()
-		ok := caCertPool.AppendCertsFromPEM(certBytes)
-
-		if ok {
-			tlsConfig.RootCAs = caCertPool
+		if *caFile != "" {
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyConnection = reloader.verifyConnection
 		}
-	}
-
-	if *certFile != "" && *keyFile != "" {
-		tlsCert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
-		if err != nil {
-			return &http.Transport{}, err
+		if *certFile != "" && *keyFile != "" {
+			tlsConfig.GetClientCertificate = reloader.getClientCertificate
 		}
-		tlsConfig.Certificates = []tls.Certificate{tlsCert}
 	}
 
 	transport.TLSClientConfig = tlsConfig
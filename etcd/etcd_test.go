@@ -0,0 +1,143 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	etcd2 "github.com/coreos/etcd/client"
+	"github.com/gliderlabs/registrator/bridge"
+	etcd "gopkg.in/coreos/go-etcd.v0/etcd"
+)
+
+// fakeKeysAPI is a stand-in for etcd2.NewKeysAPI's return value, letting
+// Services() be exercised against a canned node tree or error without a
+// real v2 server.
+type fakeKeysAPI struct {
+	resp *etcd2.Response
+	err  error
+}
+
+func (f *fakeKeysAPI) Get(ctx context.Context, key string, opts *etcd2.GetOptions) (*etcd2.Response, error) {
+	return f.resp, f.err
+}
+
+func (f *fakeKeysAPI) Set(ctx context.Context, key, value string, opts *etcd2.SetOptions) (*etcd2.Response, error) {
+	return nil, nil
+}
+
+func (f *fakeKeysAPI) Delete(ctx context.Context, key string, opts *etcd2.DeleteOptions) (*etcd2.Response, error) {
+	return nil, nil
+}
+
+func (f *fakeKeysAPI) Watcher(key string, opts *etcd2.WatcherOptions) etcd2.Watcher {
+	return nil
+}
+
+func TestServicesV2WalksTree(t *testing.T) {
+	adapter := &EtcdAdapter{
+		path: "/services",
+		kapi2: &fakeKeysAPI{
+			resp: &etcd2.Response{
+				Node: &etcd2.Node{
+					Key: "/services",
+					Dir: true,
+					Nodes: etcd2.Nodes{
+						{
+							Key: "/services/web",
+							Dir: true,
+							Nodes: etcd2.Nodes{
+								{Key: "/services/web/abc123", Value: "10.0.0.1:8080"},
+								{Key: "/services/web/def456", Value: "malformed"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	services, err := adapter.Services()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*bridge.Service{
+		{Name: "web", ID: "abc123", IP: "10.0.0.1", Port: 8080},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Fatalf("Services() = %+v, want %+v", services, want)
+	}
+}
+
+func TestServicesV2KeyNotFoundIsEmpty(t *testing.T) {
+	adapter := &EtcdAdapter{
+		path:  "/services",
+		kapi2: &fakeKeysAPI{err: etcd2.Error{Code: etcd2.ErrorCodeKeyNotFound}},
+	}
+
+	services, err := adapter.Services()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(services) != 0 {
+		t.Fatalf("Services() = %+v, want empty", services)
+	}
+}
+
+func TestServicesV2PropagatesOtherErrors(t *testing.T) {
+	wantErr := errors.New("boom")
+	adapter := &EtcdAdapter{
+		path:  "/services",
+		kapi2: &fakeKeysAPI{err: wantErr},
+	}
+
+	if _, err := adapter.Services(); err != wantErr {
+		t.Fatalf("Services() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestServiceFromNode(t *testing.T) {
+	service, err := serviceFromNode("web", "abc123", "10.0.0.1:8080")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if service.Name != "web" || service.ID != "abc123" || service.IP != "10.0.0.1" || service.Port != 8080 {
+		t.Fatalf("unexpected service: %+v", service)
+	}
+}
+
+func TestServiceFromNodeMalformedValue(t *testing.T) {
+	if _, err := serviceFromNode("web", "abc123", "not-a-hostport"); err == nil {
+		t.Fatal("expected an error for a value with no host:port")
+	}
+}
+
+func TestServiceFromNodeMalformedPort(t *testing.T) {
+	if _, err := serviceFromNode("web", "abc123", "10.0.0.1:notaport"); err == nil {
+		t.Fatal("expected an error for a non-numeric port")
+	}
+}
+
+func TestIsKeyNotFound(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"v0 key not found", &etcd.EtcdError{ErrorCode: etcd.EcodeKeyNotFound}, true},
+		{"v0 other error", &etcd.EtcdError{ErrorCode: 300}, false},
+		{"v2 key not found", etcd2.Error{Code: etcd2.ErrorCodeKeyNotFound}, true},
+		{"v2 other error", etcd2.Error{Code: 300}, false},
+		{"unrelated error", errors.New("boom"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isKeyNotFound(c.err); got != c.want {
+				t.Errorf("isKeyNotFound(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}